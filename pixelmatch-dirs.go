@@ -3,14 +3,31 @@ package main
 import (
 	"flag"
 	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"io/fs"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"text/tabwriter"
+
+	"github.com/bmatcuk/doublestar/v4"
+	_ "golang.org/x/image/webp"
+
+	"github.com/arisawa/pixelmatch-dirs/pkg/config"
+	"github.com/arisawa/pixelmatch-dirs/pkg/htmlreport"
+	"github.com/arisawa/pixelmatch-dirs/pkg/pixelmatch"
+	"github.com/arisawa/pixelmatch-dirs/pkg/report"
 )
 
 const (
@@ -22,39 +39,63 @@ var (
 	threshold        string
 	srcDir           string
 	targetDir        string
+	useDocker        bool
+	concurrency      int
+	includeGlobs     globList
+	excludeGlobs     globList
+	reportFormat     string
+	reportFile       string
+	htmlReportDir    string
 	defaultThreshold = "0.015"
 	defaultSrcDir    = "src"
 	defaultTargetDir = "target"
 	tmpDir           = "tmp"
 	container        = "arisawa/pixelmatch:v5.1.0"
+
+	// baseThreshold is threshold parsed as a float64; cfg may override it
+	// per file.
+	baseThreshold float64
+	// cfg is the optional pixelmatch.yaml / .pixelmatchrc found in srcDir.
+	// A nil cfg is valid and means "no overrides".
+	cfg *config.Config
 )
 
-type diffPixel struct {
-	file   string
-	pixels string
-	error  string
+// defaultExts are the image extensions walked by default when --include is
+// not given.
+var defaultExts = []string{".png", ".jpg", ".jpeg", ".webp"}
+
+// globList is a repeatable flag.Value that collects doublestar-style glob
+// patterns, e.g. `--exclude 'vendor/**' --exclude '**/*.tmp.png'`.
+type globList []string
+
+func (g *globList) String() string {
+	return strings.Join(*g, ",")
 }
 
-func newDiffPixel(fileName, pixelmatchOut string) *diffPixel {
-	// fmt.Println(pixelmatchOut)
-	lines := strings.Split(pixelmatchOut, "\n")
-	pixels := strings.Split(lines[1], ":")
-	errorPer := strings.Split(lines[2], ":")
-	return &diffPixel{
-		file: fileName,
-		pixels: strings.TrimSpace(pixels[1]),
-		error: strings.TrimSpace(errorPer[1]),
-	}
+func (g *globList) Set(pattern string) error {
+	*g = append(*g, pattern)
+	return nil
 }
 
 func main() {
+	flag.BoolVar(&useDocker, "docker", false, "shell out to the arisawa/pixelmatch docker container instead of the native comparator")
+	flag.IntVar(&concurrency, "j", runtime.NumCPU(), "number of files to compare concurrently")
+	flag.Var(&includeGlobs, "include", "doublestar glob (relative to SRC_DIR) of files to include; may be repeated")
+	flag.Var(&excludeGlobs, "exclude", "doublestar glob (relative to SRC_DIR) of files to exclude; may be repeated")
+	flag.StringVar(&reportFormat, "report", "", "emit a machine-readable report in addition to the text output: \"json\" or \"junit\"")
+	flag.StringVar(&reportFile, "report-file", "", "file to write the --report output to (default stdout)")
+	flag.StringVar(&htmlReportDir, "html-report", "", "write a self-contained HTML gallery of the comparisons to this directory")
 	flag.Usage = func() {
 		fmt.Printf(`Usage:
   %s THRESHOLD SRC_DIR TARGET_DIR
 
-  Compare png files in the source directory with the same name of file in the target directory by pixelmatch docker container.
+  Recursively walk the source directory and compare each png/jpg/jpeg/webp
+  file against the file of the same relative path in the target directory,
+  using a pure-Go pixelmatch port (pass --docker to shell out to the
+  arisawa/pixelmatch container instead).
     THRESHOLD string
-      threshold for pixelmatch 0 (default "%s", range is 0 to 1)
+      threshold for pixelmatch 0 (default "%s", range is 0 to 1; 0 is the
+      strictest exact-match setting, 1 matches anything)
     SRC_DIR string
       source directory (default "%s")
     TARGET_DIR string
@@ -71,103 +112,422 @@ func main() {
 	if srcDir = flag.Arg(1); srcDir == "" {
 		srcDir = defaultSrcDir
 	}
-	if targetDir = flag.Arg(1); targetDir == "" {
+	if targetDir = flag.Arg(2); targetDir == "" {
 		targetDir = defaultTargetDir
 	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
 
 	validate()
 	checkTmpDir()
 
-	diffDimensions := []string{}
-	diffPixels := []*diffPixel{}
-
-	files, err := ioutil.ReadDir(srcDir)
+	baseThreshold, _ = strconv.ParseFloat(threshold, 64)
+	var err error
+	cfg, err = config.Load(srcDir)
 	if err != nil {
-		log.Fatalf("read %s error: %s", srcDir, err)
+		log.Fatalf("config error: %v", err)
 	}
-	for _, f := range files {
-		fileName := f.Name()
-		if !strings.HasSuffix(fileName, ".png") {
-			continue
+
+	relPaths := collectFiles()
+	entries := compareAll(relPaths)
+
+	printText(entries)
+
+	if reportFormat != "" {
+		if err := writeReport(entries); err != nil {
+			log.Fatalf("report error: %v", err)
 		}
+	}
 
-		srcFile := filepath.Join(srcDir, fileName)
-		targetFile := filepath.Join(targetDir, fileName)
+	if htmlReportDir != "" {
+		if err := htmlreport.Generate(htmlReportDir, srcDir, targetDir, report.NewSummary(entries)); err != nil {
+			log.Fatalf("html report error: %v", err)
+		}
+	}
+}
 
-		if _, err := os.Stat(targetFile); os.IsNotExist(err) {
-			continue
+// printText renders the original dimensions/pixels tables to stdout.
+func printText(entries []report.Entry) {
+	diffDimensions := []string{}
+	diffPixels := []report.Entry{}
+	for _, e := range entries {
+		switch e.Status {
+		case report.StatusDifferentDimensions:
+			diffDimensions = append(diffDimensions, e.File)
+		case report.StatusDifferentPixels:
+			diffPixels = append(diffPixels, e)
 		}
+	}
 
-		tmpSrcFile := filepath.Join(tmpDir, fmt.Sprintf("src-%s", fileName))
-		tmpTargetFile := filepath.Join(tmpDir, fmt.Sprintf("target-%s", fileName))
+	if len(diffDimensions) > 0 {
+		fmt.Println("-- dimensions do not match --")
+		for _, f := range diffDimensions {
+			fmt.Println(f)
+		}
+	}
 
-		copyFile(srcFile, tmpSrcFile)
-		copyFile(targetFile, tmpTargetFile)
+	if len(diffPixels) > 0 {
+		fmt.Println("-- Different pixels are found --")
+		w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
+		for _, e := range diffPixels {
+			fmt.Fprintf(w, "%s\t%d\t%.4f%%\n", e.File, e.DiffPixels, e.DiffRatio)
+		}
+		w.Flush()
+	}
+}
 
-		diffFileName := fmt.Sprintf("diff-%s", fileName)
-		diffFile := filepath.Join(tmpDir, diffFileName)
+// writeReport renders entries in --report's format to --report-file, or
+// stdout when --report-file is unset.
+func writeReport(entries []report.Entry) error {
+	out := os.Stdout
+	if reportFile != "" {
+		f, err := os.Create(reportFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
 
-		fmt.Printf("check %s\n", srcFile)
+	summary := report.NewSummary(entries)
+	switch reportFormat {
+	case "json":
+		return summary.WriteJSON(out)
+	case "junit":
+		return summary.WriteJUnit(out)
+	default:
+		return fmt.Errorf("unknown report format: %q (want \"json\" or \"junit\")", reportFormat)
+	}
+}
 
-		absTmpDir, err := filepath.Abs(tmpDir)
+// collectFiles walks srcDir recursively and returns the slash-separated
+// paths, relative to srcDir, of files eligible for comparison. Without
+// --include, any file under defaultExts is eligible; with --include, a file
+// must match at least one include glob. A file matching any --exclude glob
+// is always skipped. Globs are matched doublestar-style against the
+// slash-separated relative path, so patterns like `vendor/**` or
+// `**/*.jpg` work regardless of nesting. The returned paths are not yet
+// filtered by whether a target counterpart exists - that's reported as
+// report.StatusMissingTarget during comparison instead of silently
+// skipped.
+func collectFiles() []string {
+	relPaths := []string{}
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			log.Fatalf("cannot get absolute path: %s", tmpDir)
-		}
-		volume := fmt.Sprintf("%s:/app/%s", absTmpDir, tmpDir)
-		cmd := exec.Command(
-			"docker", "run", "--rm", "-v", volume, container,
-			tmpSrcFile, tmpTargetFile, diffFile, threshold,
-		)
-		out, err := cmd.CombinedOutput()
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(srcDir, path)
 		if err != nil {
-			switch cmd.ProcessState.ExitCode() {
-			case exitCodeDifferentDimension:
-				diffDimensions = append(diffDimensions, fileName)
-			case exitCodeDifferentPixels:
-				diffPixels = append(diffPixels, newDiffPixel(fileName, string(out)))
-				if err := os.Rename(diffFile, diffFileName); err != nil {
-					if err != nil {
-						log.Fatalf("file move error: %v", err)
-					}
-				}
-			default:
-				log.Fatalf("command execution error: %v", err)
-			}
-		} else {
-			if err := os.Remove(diffFile); err != nil {
-				if err != nil {
-					log.Fatalf("file remove error: %v", err)
-				}
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if matchesAny(excludeGlobs, relPath) {
+			return nil
+		}
+		if len(includeGlobs) > 0 {
+			if !matchesAny(includeGlobs, relPath) {
+				return nil
 			}
+		} else if !hasDefaultExt(relPath) {
+			return nil
 		}
-		if err := os.Remove(tmpSrcFile); err != nil {
-			log.Fatalf("tmp source file remove error: %v", err)
+
+		relPaths = append(relPaths, relPath)
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("walk %s error: %s", srcDir, err)
+	}
+	return relPaths
+}
+
+func matchesAny(globs globList, relPath string) bool {
+	for _, pattern := range globs {
+		if ok, err := doublestar.Match(pattern, relPath); err == nil && ok {
+			return true
 		}
-		if err := os.Remove(tmpTargetFile); err != nil {
-			log.Fatalf("tmp source file remove error: %v", err)
+	}
+	return false
+}
+
+func hasDefaultExt(relPath string) bool {
+	ext := strings.ToLower(filepath.Ext(relPath))
+	for _, e := range defaultExts {
+		if ext == e {
+			return true
 		}
 	}
+	return false
+}
 
-	if len(diffDimensions) > 0 {
-		fmt.Println("-- dimensions do not match --")
-		for _, f := range diffDimensions {
-			fmt.Println(f)
+// compareAll fans relPaths out across `concurrency` worker goroutines and
+// collects the results in original file order, so the final report stays
+// deterministic regardless of which worker finishes a given file first. A
+// "[done/total] check file" progress line is written to stderr as each
+// worker picks up a file.
+func compareAll(relPaths []string) []report.Entry {
+	total := len(relPaths)
+	entries := make([]report.Entry, total)
+
+	jobs := make(chan int)
+	var done int32
+	var wg sync.WaitGroup
+
+	worker := func() {
+		defer wg.Done()
+		for i := range jobs {
+			relPath := relPaths[i]
+			n := atomic.AddInt32(&done, 1)
+			fmt.Fprintf(os.Stderr, "[%d/%d] check %s\n", n, total, relPath)
+
+			entries[i] = compareFile(relPath)
 		}
 	}
 
-	if len(diffPixels) > 0 {
-		fmt.Println("-- Different pixels are found --")
-		w := tabwriter.NewWriter(os.Stdout, 0, 8, 0, '\t', 0)
-		for _, dp := range diffPixels {
-			fmt.Fprintf(w, "%s\t%s\t%s\n", dp.file, dp.pixels, dp.error)
+	wg.Add(concurrency)
+	for w := 0; w < concurrency; w++ {
+		go worker()
+	}
+	for i := 0; i < total; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return entries
+}
+
+// compareFile compares the file at relPath under srcDir against its
+// counterpart under targetDir, via the native comparator or --docker.
+func compareFile(relPath string) report.Entry {
+	srcFile := filepath.Join(srcDir, filepath.FromSlash(relPath))
+	targetFile := filepath.Join(targetDir, filepath.FromSlash(relPath))
+
+	if _, err := os.Stat(targetFile); os.IsNotExist(err) {
+		return report.Entry{File: relPath, Status: report.StatusMissingTarget}
+	}
+
+	if useDocker {
+		return compareDocker(relPath, srcFile, targetFile)
+	}
+	return compareNative(relPath, srcFile, targetFile)
+}
+
+// compareNative compares srcFile against targetFile using the in-process
+// pkg/pixelmatch comparator and writes a diff-* image alongside the source
+// tree on mismatch, mirroring relPath's directory structure. Any ignore
+// regions configured for relPath are blanked out in both images before
+// comparison, and any threshold override takes the place of the global
+// threshold.
+func compareNative(relPath, srcFile, targetFile string) report.Entry {
+	srcImg := decodeImage(srcFile)
+	targetImg := decodeImage(targetFile)
+
+	b1, b2 := srcImg.Bounds(), targetImg.Bounds()
+	srcSize := report.Size{Width: b1.Dx(), Height: b1.Dy()}
+	targetSize := report.Size{Width: b2.Dx(), Height: b2.Dy()}
+	if srcSize != targetSize {
+		return report.Entry{
+			File:       relPath,
+			Status:     report.StatusDifferentDimensions,
+			SrcSize:    srcSize,
+			TargetSize: targetSize,
 		}
-		w.Flush()
+	}
+
+	regions := cfg.IgnoreRegions(relPath)
+	srcImg = maskedImage(srcImg, regions)
+	targetImg = maskedImage(targetImg, regions)
+
+	out := image.NewRGBA(b1)
+	fileThreshold := cfg.Threshold(relPath, baseThreshold)
+	numDiff, err := pixelmatch.Compare(srcImg, targetImg, out, pixelmatch.Options{
+		Threshold: &fileThreshold,
+	})
+	if err != nil {
+		log.Fatalf("compare %s error: %v", relPath, err)
+	}
+	if numDiff == 0 {
+		return report.Entry{File: relPath, Status: report.StatusMatch, SrcSize: srcSize, TargetSize: targetSize}
+	}
+
+	base := filepath.Base(relPath)
+	base = strings.TrimSuffix(base, filepath.Ext(base)) + ".png"
+	diffFile := filepath.Join(filepath.Dir(relPath), fmt.Sprintf("diff-%s", base))
+	if dir := filepath.Dir(diffFile); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Fatalf("diff directory error: %v", err)
+		}
+	}
+	writePNG(diffFile, out)
+
+	return report.Entry{
+		File:       relPath,
+		Status:     report.StatusDifferentPixels,
+		DiffPixels: numDiff,
+		DiffRatio:  float64(numDiff) / float64(b1.Dx()*b1.Dy()) * 100,
+		SrcSize:    srcSize,
+		TargetSize: targetSize,
+		DiffImage:  diffFile,
+	}
+}
+
+// compareDocker preserves the original v5.1.0 behaviour of shelling out to
+// the arisawa/pixelmatch docker image, for users who pass --docker. Nested
+// relPaths are flattened into tmpDir, since the docker image only ever
+// dealt with a flat directory of files. Any ignore regions configured for
+// relPath are blanked out in the tmpDir copies before the container runs,
+// and any threshold override takes the place of the global threshold.
+func compareDocker(relPath, srcFile, targetFile string) report.Entry {
+	srcSize := imageSize(srcFile)
+	targetSize := imageSize(targetFile)
+
+	flatName := strings.ReplaceAll(relPath, "/", "_")
+	tmpSrcFile := filepath.Join(tmpDir, fmt.Sprintf("src-%s", flatName))
+	tmpTargetFile := filepath.Join(tmpDir, fmt.Sprintf("target-%s", flatName))
+
+	regions := cfg.IgnoreRegions(relPath)
+	if len(regions) > 0 {
+		writePNG(tmpSrcFile, maskedImage(decodeImage(srcFile), regions))
+		writePNG(tmpTargetFile, maskedImage(decodeImage(targetFile), regions))
+	} else {
+		copyFile(srcFile, tmpSrcFile)
+		copyFile(targetFile, tmpTargetFile)
+	}
+
+	diffFileName := fmt.Sprintf("diff-%s", flatName)
+	diffFile := filepath.Join(tmpDir, diffFileName)
+
+	absTmpDir, err := filepath.Abs(tmpDir)
+	if err != nil {
+		log.Fatalf("cannot get absolute path: %s", tmpDir)
+	}
+	volume := fmt.Sprintf("%s:/app/%s", absTmpDir, tmpDir)
+	thresholdStr := strconv.FormatFloat(cfg.Threshold(relPath, baseThreshold), 'f', -1, 64)
+	cmd := exec.Command(
+		"docker", "run", "--rm", "-v", volume, container,
+		tmpSrcFile, tmpTargetFile, diffFile, thresholdStr,
+	)
+
+	entry := report.Entry{File: relPath, SrcSize: srcSize, TargetSize: targetSize}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		switch cmd.ProcessState.ExitCode() {
+		case exitCodeDifferentDimension:
+			entry.Status = report.StatusDifferentDimensions
+		case exitCodeDifferentPixels:
+			entry.Status = report.StatusDifferentPixels
+			entry.DiffPixels, entry.DiffRatio = parseDockerOutput(string(out))
+			if err := os.Rename(diffFile, diffFileName); err != nil {
+				log.Fatalf("file move error: %v", err)
+			}
+			entry.DiffImage = diffFileName
+		default:
+			log.Fatalf("command execution error: %v", err)
+		}
+	} else {
+		entry.Status = report.StatusMatch
+		if err := os.Remove(diffFile); err != nil {
+			log.Fatalf("file remove error: %v", err)
+		}
+	}
+
+	if err := os.Remove(tmpSrcFile); err != nil {
+		log.Fatalf("tmp source file remove error: %v", err)
+	}
+	if err := os.Remove(tmpTargetFile); err != nil {
+		log.Fatalf("tmp source file remove error: %v", err)
+	}
+
+	return entry
+}
+
+// parseDockerOutput parses the docker container's "pixels: N\nerror: X%"
+// output into a pixel count and percentage.
+func parseDockerOutput(pixelmatchOut string) (pixels int, ratio float64) {
+	lines := strings.Split(pixelmatchOut, "\n")
+	pixels, _ = strconv.Atoi(strings.TrimSpace(strings.Split(lines[1], ":")[1]))
+	ratio, _ = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(strings.Split(lines[2], ":")[1]), "%"), 64)
+	return pixels, ratio
+}
+
+func imageSize(path string) report.Size {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("open %s error: %v", path, err)
+	}
+	defer f.Close()
+
+	imgCfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		log.Fatalf("decode %s error: %v", path, err)
+	}
+	return report.Size{Width: imgCfg.Width, Height: imgCfg.Height}
+}
+
+// maskedImage fills regions with solid black, returning img unchanged when
+// there are no regions to blank out. This is how ignore rectangles from
+// pixelmatch.yaml stop dynamic content (timestamps, avatars, animated
+// widgets) from producing false-positive diffs.
+func maskedImage(img image.Image, regions []config.Rect) image.Image {
+	if len(regions) == 0 {
+		return img
+	}
+
+	b := img.Bounds()
+	rgba := image.NewRGBA(b)
+	draw.Draw(rgba, b, img, b.Min, draw.Src)
+
+	fill := image.NewUniform(color.RGBA{0, 0, 0, 255})
+	for _, r := range regions {
+		rect := image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H).Intersect(b)
+		if rect.Empty() {
+			continue
+		}
+		draw.Draw(rgba, rect, fill, image.Point{}, draw.Src)
+	}
+
+	return rgba
+}
+
+func decodeImage(path string) image.Image {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("open %s error: %v", path, err)
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		log.Fatalf("decode %s error: %v", path, err)
+	}
+	return img
+}
+
+func writePNG(path string, img image.Image) {
+	f, err := os.Create(path)
+	if err != nil {
+		log.Fatalf("create %s error: %v", path, err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, img); err != nil {
+		log.Fatalf("encode %s error: %v", path, err)
 	}
 }
 
 func validate() {
-	if _, err := exec.LookPath("docker"); err != nil {
-		log.Fatal("docker is not installed")
+	if useDocker {
+		if _, err := exec.LookPath("docker"); err != nil {
+			log.Fatal("docker is not installed")
+		}
 	}
 
 	if _, err := strconv.ParseFloat(threshold, 32); err != nil {
@@ -188,6 +548,10 @@ func validate() {
 	if !stat.IsDir() {
 		log.Fatalf("target: %s is not directory", targetDir)
 	}
+
+	if reportFormat != "" && reportFormat != "json" && reportFormat != "junit" {
+		log.Fatalf("report format error: %q (want \"json\" or \"junit\")", reportFormat)
+	}
 }
 
 func checkTmpDir() {
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/arisawa/pixelmatch-dirs/pkg/report"
+)
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestCollectFilesHonorsIncludeExcludeAndDefaultExts(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{
+		"a.png",
+		"b.jpg",
+		"notes.txt",
+		"nested/c.webp",
+		"nested/deep/d.png",
+		"vendor/e.png",
+	} {
+		writeFile(t, filepath.Join(dir, p))
+	}
+
+	origSrcDir, origInclude, origExclude := srcDir, includeGlobs, excludeGlobs
+	defer func() { srcDir, includeGlobs, excludeGlobs = origSrcDir, origInclude, origExclude }()
+
+	t.Run("default extensions, no globs", func(t *testing.T) {
+		srcDir, includeGlobs, excludeGlobs = dir, nil, nil
+		got := collectFiles()
+		sort.Strings(got)
+		want := []string{"a.png", "b.jpg", "nested/c.webp", "nested/deep/d.png", "vendor/e.png"}
+		assertSameFiles(t, got, want)
+	})
+
+	t.Run("exclude wins over default extensions", func(t *testing.T) {
+		srcDir, includeGlobs, excludeGlobs = dir, nil, globList{"vendor/**"}
+		got := collectFiles()
+		sort.Strings(got)
+		want := []string{"a.png", "b.jpg", "nested/c.webp", "nested/deep/d.png"}
+		assertSameFiles(t, got, want)
+	})
+
+	t.Run("include narrows to a glob, exclude still wins", func(t *testing.T) {
+		srcDir, includeGlobs, excludeGlobs = dir, globList{"**/*.png"}, globList{"vendor/**"}
+		got := collectFiles()
+		sort.Strings(got)
+		want := []string{"a.png", "nested/deep/d.png"}
+		assertSameFiles(t, got, want)
+	})
+
+	t.Run("include also picks up non-default extensions", func(t *testing.T) {
+		srcDir, includeGlobs, excludeGlobs = dir, globList{"**/*.txt"}, nil
+		got := collectFiles()
+		want := []string{"notes.txt"}
+		assertSameFiles(t, got, want)
+	})
+}
+
+func TestCompareAllPreservesOrder(t *testing.T) {
+	srcRoot, targetRoot := t.TempDir(), t.TempDir()
+
+	const n = 40
+	relPaths := make([]string, n)
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{10, 20, 30, 255})
+	for i := 0; i < n; i++ {
+		relPaths[i] = fmt.Sprintf("file-%02d.png", i)
+		writePNG(filepath.Join(srcRoot, relPaths[i]), img)
+		writePNG(filepath.Join(targetRoot, relPaths[i]), img)
+	}
+
+	origSrcDir, origTargetDir, origConcurrency, origCfg := srcDir, targetDir, concurrency, cfg
+	defer func() { srcDir, targetDir, concurrency, cfg = origSrcDir, origTargetDir, origConcurrency, origCfg }()
+	srcDir, targetDir, concurrency, cfg = srcRoot, targetRoot, 8, nil
+
+	entries := compareAll(relPaths)
+
+	if len(entries) != n {
+		t.Fatalf("len(entries) = %d, want %d", len(entries), n)
+	}
+	for i, e := range entries {
+		if e.File != relPaths[i] {
+			t.Fatalf("entries[%d].File = %q, want %q: compareAll must return results in relPaths order regardless of worker completion order", i, e.File, relPaths[i])
+		}
+		if e.Status != report.StatusMatch {
+			t.Fatalf("entries[%d].Status = %q, want %q", i, e.Status, report.StatusMatch)
+		}
+	}
+}
+
+func assertSameFiles(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("collectFiles() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("collectFiles() = %v, want %v", got, want)
+		}
+	}
+}
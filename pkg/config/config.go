@@ -0,0 +1,92 @@
+// Package config loads the optional pixelmatch.yaml (or .pixelmatchrc)
+// config file that lets callers declare per-file ignore rectangles and
+// threshold overrides, so dynamic content (timestamps, avatars, animated
+// widgets) doesn't flood every run with false positives.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"gopkg.in/yaml.v3"
+)
+
+// Rect is an ignore region, in pixel coordinates of the compared images.
+type Rect struct {
+	X int `yaml:"x"`
+	Y int `yaml:"y"`
+	W int `yaml:"w"`
+	H int `yaml:"h"`
+}
+
+// FileRule is one entry of the `files:` list: a glob pattern plus the
+// overrides that apply to any file matching it.
+type FileRule struct {
+	Pattern   string   `yaml:"pattern"`
+	Threshold *float64 `yaml:"threshold"`
+	Ignore    []Rect   `yaml:"ignore"`
+}
+
+// Config is the parsed contents of pixelmatch.yaml / .pixelmatchrc.
+type Config struct {
+	Files []FileRule `yaml:"files"`
+}
+
+// fileNames are tried, in order, in the directory passed to Load.
+var fileNames = []string{"pixelmatch.yaml", "pixelmatch.yml", ".pixelmatchrc"}
+
+// Load looks for a config file directly under dir and parses it. It
+// returns a nil *Config, with no error, when none of fileNames exist -
+// callers can use a nil *Config exactly as they would an empty one.
+func Load(dir string) (*Config, error) {
+	for _, name := range fileNames {
+		path := filepath.Join(dir, name)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+
+		var cfg Config
+		if err := yaml.Unmarshal(b, &cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		return &cfg, nil
+	}
+	return nil, nil
+}
+
+// match returns the first rule whose pattern matches relPath, or nil.
+func (c *Config) match(relPath string) *FileRule {
+	if c == nil {
+		return nil
+	}
+	for i, rule := range c.Files {
+		if ok, err := doublestar.Match(rule.Pattern, relPath); err == nil && ok {
+			return &c.Files[i]
+		}
+	}
+	return nil
+}
+
+// Threshold returns the per-file threshold override declared for relPath,
+// or base when there is no override.
+func (c *Config) Threshold(relPath string, base float64) float64 {
+	if rule := c.match(relPath); rule != nil && rule.Threshold != nil {
+		return *rule.Threshold
+	}
+	return base
+}
+
+// IgnoreRegions returns the ignore rectangles declared for relPath, or nil
+// when there are none.
+func (c *Config) IgnoreRegions(relPath string) []Rect {
+	if rule := c.match(relPath); rule != nil {
+		return rule.Ignore
+	}
+	return nil
+}
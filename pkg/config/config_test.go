@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadReturnsNilWhenNoConfigFileExists(t *testing.T) {
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg != nil {
+		t.Errorf("cfg = %+v, want nil", cfg)
+	}
+
+	if threshold := cfg.Threshold("any.png", 0.1); threshold != 0.1 {
+		t.Errorf("Threshold on nil *Config = %v, want base 0.1", threshold)
+	}
+	if regions := cfg.IgnoreRegions("any.png"); regions != nil {
+		t.Errorf("IgnoreRegions on nil *Config = %v, want nil", regions)
+	}
+}
+
+func TestLoadParsesFileRules(t *testing.T) {
+	dir := t.TempDir()
+	contents := `
+files:
+  - pattern: "avatars/**/*.png"
+    threshold: 0.5
+    ignore:
+      - {x: 0, y: 0, w: 10, h: 10}
+  - pattern: "*.png"
+    threshold: 0.2
+`
+	if err := os.WriteFile(filepath.Join(dir, "pixelmatch.yaml"), []byte(contents), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg == nil {
+		t.Fatal("cfg = nil, want parsed config")
+	}
+
+	if got := cfg.Threshold("avatars/user-1.png", 0.1); got != 0.5 {
+		t.Errorf("Threshold(avatars/user-1.png) = %v, want 0.5", got)
+	}
+	if got := cfg.Threshold("logo.png", 0.1); got != 0.2 {
+		t.Errorf("Threshold(logo.png) = %v, want 0.2", got)
+	}
+	if got := cfg.Threshold("other/file.jpg", 0.1); got != 0.1 {
+		t.Errorf("Threshold(other/file.jpg) = %v, want base 0.1", got)
+	}
+
+	regions := cfg.IgnoreRegions("avatars/user-1.png")
+	if len(regions) != 1 || regions[0] != (Rect{X: 0, Y: 0, W: 10, H: 10}) {
+		t.Errorf("IgnoreRegions(avatars/user-1.png) = %+v, want [{0 0 10 10}]", regions)
+	}
+	if regions := cfg.IgnoreRegions("logo.png"); regions != nil {
+		t.Errorf("IgnoreRegions(logo.png) = %+v, want nil", regions)
+	}
+}
+
+func TestLoadSurfacesParseErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pixelmatch.yaml"), []byte("not: [valid"), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if _, err := Load(dir); err == nil {
+		t.Error("Load with malformed YAML: got nil error, want non-nil")
+	}
+}
@@ -0,0 +1,100 @@
+// Package htmlreport renders a self-contained HTML gallery for browsing a
+// pixelmatch-dirs run: a CI-friendly report (see pkg/report) tells you
+// something failed, but a reviewer still needs to look at the pixels.
+package htmlreport
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/arisawa/pixelmatch-dirs/pkg/report"
+)
+
+//go:embed templates/index.html.tmpl
+var templatesFS embed.FS
+
+var tmpl = template.Must(template.ParseFS(templatesFS, "templates/index.html.tmpl"))
+
+// item is what the template renders for one entry: the report.Entry plus
+// the local, gallery-relative copies of its images.
+type item struct {
+	report.Entry
+	SrcImage    string
+	TargetImage string
+}
+
+type page struct {
+	Totals report.Totals
+	Items  []item
+}
+
+// Generate writes a self-contained gallery (index.html plus copied
+// src-/target-/diff- images) to dir, so a reviewer can browse every
+// comparison - side by side, with a slider overlay - without re-running
+// the tool. srcDir and targetDir are the roots each entry's File is
+// relative to.
+func Generate(dir, srcDir, targetDir string, summary report.Summary) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	items := make([]item, 0, len(summary.Entries))
+	for _, e := range summary.Entries {
+		it := item{Entry: e}
+		flat := flattenName(e.File)
+
+		if e.Status != report.StatusMissingTarget {
+			it.SrcImage = "src-" + flat
+			if err := copyFile(filepath.Join(srcDir, filepath.FromSlash(e.File)), filepath.Join(dir, it.SrcImage)); err != nil {
+				return err
+			}
+			it.TargetImage = "target-" + flat
+			if err := copyFile(filepath.Join(targetDir, filepath.FromSlash(e.File)), filepath.Join(dir, it.TargetImage)); err != nil {
+				return err
+			}
+		}
+
+		if e.DiffImage != "" {
+			diffName := "diff-" + flat
+			if err := copyFile(e.DiffImage, filepath.Join(dir, diffName)); err != nil {
+				return err
+			}
+			it.Entry.DiffImage = diffName
+		}
+
+		items = append(items, it)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return tmpl.Execute(f, page{Totals: summary.Totals, Items: items})
+}
+
+func flattenName(relPath string) string {
+	return strings.ReplaceAll(relPath, "/", "_")
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
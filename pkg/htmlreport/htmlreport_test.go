@@ -0,0 +1,70 @@
+package htmlreport
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/arisawa/pixelmatch-dirs/pkg/report"
+)
+
+func writeTestPNG(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer f.Close()
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.Set(0, 0, color.RGBA{1, 2, 3, 255})
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+}
+
+func TestGenerateCopiesImagesAndRendersIndex(t *testing.T) {
+	srcDir, targetDir, outDir := t.TempDir(), t.TempDir(), t.TempDir()
+
+	writeTestPNG(t, filepath.Join(srcDir, "nested/a.png"))
+	writeTestPNG(t, filepath.Join(targetDir, "nested/a.png"))
+	diffImage := filepath.Join(targetDir, "diff-a.png")
+	writeTestPNG(t, diffImage)
+
+	summary := report.NewSummary([]report.Entry{
+		{File: "nested/a.png", Status: report.StatusDifferentPixels, DiffPixels: 2, DiffRatio: 50, DiffImage: diffImage},
+		{File: "missing.png", Status: report.StatusMissingTarget},
+	})
+
+	if err := Generate(outDir, srcDir, targetDir, summary); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+
+	for _, name := range []string{"src-nested_a.png", "target-nested_a.png", "diff-nested_a.png"} {
+		if _, err := os.Stat(filepath.Join(outDir, name)); err != nil {
+			t.Errorf("expected gallery file %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "src-missing.png")); !os.IsNotExist(err) {
+		t.Errorf("missing-target entry should not have copied a src image, stat err = %v", err)
+	}
+
+	indexBytes, err := os.ReadFile(filepath.Join(outDir, "index.html"))
+	if err != nil {
+		t.Fatalf("ReadFile index.html: %v", err)
+	}
+	index := string(indexBytes)
+
+	for _, want := range []string{"nested/a.png", "src-nested_a.png", "target-nested_a.png", "diff-nested_a.png", "missing.png"} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index.html missing reference to %q", want)
+		}
+	}
+}
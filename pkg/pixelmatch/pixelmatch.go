@@ -0,0 +1,319 @@
+// Package pixelmatch is a pure-Go port of the pixel-level image comparison
+// algorithm used by github.com/mapbox/pixelmatch. It compares two images of
+// identical dimensions, flags perceptually different pixels using a YIQ
+// color-distance metric, optionally detects and skips anti-aliased edges,
+// and can paint the differences onto an output image.
+package pixelmatch
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// ErrDimensionMismatch is returned by Compare when img1 and img2 do not have
+// the same width and height.
+var ErrDimensionMismatch = errors.New("pixelmatch: image dimensions do not match")
+
+// Options controls how Compare decides whether two pixels are "different".
+type Options struct {
+	// Threshold is the matching threshold, ranging from 0 (strict) to 1
+	// (very loose). Defaults to 0.1 when nil; unlike a bare float64, a nil
+	// Threshold lets callers pass an explicit 0 without it being mistaken
+	// for "unset".
+	Threshold *float64
+	// IncludeAA disables anti-aliasing detection, so anti-aliased pixels
+	// count as differences too.
+	IncludeAA bool
+	// Alpha is the opacity of the original image in the diff output,
+	// ranging from 0 to 1. Defaults to 0.1 when zero.
+	Alpha float64
+	// AAColor is used to paint anti-aliased pixels in the diff output.
+	// Defaults to color.RGBA{255, 255, 0, 255} (yellow) when nil.
+	AAColor color.Color
+	// DiffColor is used to paint different pixels in the diff output.
+	// Defaults to color.RGBA{255, 0, 0, 255} (red) when nil.
+	DiffColor color.Color
+	// DiffMask draws the diff over a transparent background instead of
+	// blending it with the original image.
+	DiffMask bool
+}
+
+const (
+	defaultThreshold = 0.1
+	defaultAlpha     = 0.1
+
+	// yiqMaxDeltaSquared is the largest possible squared YIQ color
+	// distance between two colors.
+	yiqMaxDeltaSquared = 35215
+)
+
+var (
+	defaultAAColor   = color.RGBA{255, 255, 0, 255}
+	defaultDiffColor = color.RGBA{255, 0, 0, 255}
+)
+
+func (o Options) withDefaults() Options {
+	if o.Threshold == nil {
+		t := defaultThreshold
+		o.Threshold = &t
+	}
+	if o.Alpha == 0 {
+		o.Alpha = defaultAlpha
+	}
+	if o.AAColor == nil {
+		o.AAColor = defaultAAColor
+	}
+	if o.DiffColor == nil {
+		o.DiffColor = defaultDiffColor
+	}
+	return o
+}
+
+// Compare compares img1 against img2 pixel by pixel and returns the number
+// of pixels found to be different. Both images must share the same bounds
+// size. If out is non-nil, it is painted with the diff: matching pixels are
+// faded per opts.Alpha, and differing pixels are painted opts.DiffColor.
+// Anti-aliased pixels are painted opts.AAColor, unless opts.DiffMask is set,
+// in which case only opts.DiffColor is painted and everything else (matches
+// and anti-aliasing alike) is left transparent.
+func Compare(img1, img2 image.Image, out draw.Image, opts Options) (numDiff int, err error) {
+	b1, b2 := img1.Bounds(), img2.Bounds()
+	w, h := b1.Dx(), b1.Dy()
+	if w != b2.Dx() || h != b2.Dy() {
+		return 0, ErrDimensionMismatch
+	}
+
+	opts = opts.withDefaults()
+	a := newRGBAImage(img1)
+	b := newRGBAImage(img2)
+	maxDelta := yiqMaxDeltaSquared * *opts.Threshold * *opts.Threshold
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			pos := (y*w + x) * 4
+
+			delta := colorDelta(a, b, pos, pos, false)
+			if abs64(delta) > maxDelta {
+				isAA := false
+				if !opts.IncludeAA {
+					isAA = antialiased(a, x, y, w, h, b) || antialiased(b, x, y, w, h, a)
+				}
+				if isAA {
+					if !opts.DiffMask {
+						paintPixel(out, x, y, opts.AAColor)
+					}
+				} else {
+					paintPixel(out, x, y, opts.DiffColor)
+					numDiff++
+				}
+			} else if out != nil {
+				paintMatch(out, x, y, a, pos, opts)
+			}
+		}
+	}
+
+	return numDiff, nil
+}
+
+// rgbaImage holds pre-decoded RGBA samples for fast repeated pixel access,
+// since image.Image.At() on arbitrary color models is too slow for the
+// per-pixel, per-neighbor access pattern anti-aliasing detection needs.
+type rgbaImage struct {
+	pix           []uint8 // RGBA, 4 bytes per pixel, row-major
+	width, height int
+}
+
+func newRGBAImage(img image.Image) *rgbaImage {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Stride == rgba.Rect.Dx()*4 && rgba.Rect.Min == (image.Point{}) {
+		return &rgbaImage{pix: rgba.Pix, width: rgba.Rect.Dx(), height: rgba.Rect.Dy()}
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	return &rgbaImage{pix: dst.Pix, width: w, height: h}
+}
+
+func (img *rgbaImage) at(pos int) (r, g, b, a uint32) {
+	return uint32(img.pix[pos]), uint32(img.pix[pos+1]), uint32(img.pix[pos+2]), uint32(img.pix[pos+3])
+}
+
+// colorDelta computes the squared YIQ color distance between the pixel at
+// posA in img1 and the pixel at posB in img2. When onlyBrightness is true,
+// only the Y (luma) channel is considered, which antialiased uses to order
+// sibling pixels by brightness.
+func colorDelta(img1, img2 *rgbaImage, posA, posB int, onlyBrightness bool) float64 {
+	r1, g1, b1, a1 := img1.at(posA)
+	r2, g2, b2, a2 := img2.at(posB)
+
+	if a1 == a2 && r1 == r2 && g1 == g2 && b1 == b2 {
+		return 0
+	}
+
+	fr1, fg1, fb1 := blend(r1, g1, b1, a1)
+	fr2, fg2, fb2 := blend(r2, g2, b2, a2)
+
+	y1 := rgb2y(fr1, fg1, fb1)
+	y2 := rgb2y(fr2, fg2, fb2)
+	y := y1 - y2
+
+	if onlyBrightness {
+		return y
+	}
+
+	i := rgb2i(fr1, fg1, fb1) - rgb2i(fr2, fg2, fb2)
+	q := rgb2q(fr1, fg1, fb1) - rgb2q(fr2, fg2, fb2)
+
+	delta := 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+
+	if y1 > y2 {
+		return -delta
+	}
+	return delta
+}
+
+// blend blends the color's RGB channels against a white background
+// according to its alpha, since pixelmatch compares colors as they would
+// be composited over white rather than their raw (possibly transparent)
+// values.
+func blend(r, g, b, a uint32) (float64, float64, float64) {
+	if a == 255 {
+		return float64(r), float64(g), float64(b)
+	}
+	alpha := float64(a) / 255
+	return 255 + (float64(r)-255)*alpha,
+		255 + (float64(g)-255)*alpha,
+		255 + (float64(b)-255)*alpha
+}
+
+func rgb2y(r, g, b float64) float64 { return r*0.29889531 + g*0.58662247 + b*0.11448223 }
+func rgb2i(r, g, b float64) float64 { return r*0.59597799 - g*0.27417610 - b*0.32180189 }
+func rgb2q(r, g, b float64) float64 { return r*0.21147017 - g*0.52261711 + b*0.31114694 }
+
+// antialiased reports whether the pixel at (x, y) in img is likely part of
+// an anti-aliased edge rather than a genuine difference: it has at most 2
+// sibling pixels identical to itself, it has both a darker and a brighter
+// sibling, and the darkest or the brightest sibling has 3 or more identical
+// neighbors of its own in both img and other.
+func antialiased(img *rgbaImage, x, y, w, h int, other *rgbaImage) bool {
+	x0, y0 := max0(x-1), max0(y-1)
+	x1, y1 := min(x+1, w-1), min(y+1, h-1)
+	pos := (y*w + x) * 4
+
+	// A pixel on the image border has fewer than 8 neighbors, so it's
+	// seeded with one "missing" zero to avoid being mistaken for
+	// anti-aliasing just because it couldn't accumulate 3 identical
+	// siblings.
+	zeroes := 0
+	if x == x0 || x == x1 || y == y0 || y == y1 {
+		zeroes = 1
+	}
+	var minDelta, maxDelta float64
+	var minX, minY, maxX, maxY int
+
+	for sy := y0; sy <= y1; sy++ {
+		for sx := x0; sx <= x1; sx++ {
+			if sx == x && sy == y {
+				continue
+			}
+
+			delta := colorDelta(img, img, pos, (sy*w+sx)*4, true)
+
+			if delta == 0 {
+				zeroes++
+				if zeroes > 2 {
+					return false
+				}
+				continue
+			}
+
+			if delta < minDelta {
+				minDelta = delta
+				minX, minY = sx, sy
+			}
+			if delta > maxDelta {
+				maxDelta = delta
+				maxX, maxY = sx, sy
+			}
+		}
+	}
+
+	if minDelta == 0 || maxDelta == 0 {
+		return false
+	}
+
+	return (hasManySiblings(img, minX, minY, w, h) && hasManySiblings(other, minX, minY, w, h)) ||
+		(hasManySiblings(img, maxX, maxY, w, h) && hasManySiblings(other, maxX, maxY, w, h))
+}
+
+// hasManySiblings reports whether the pixel at (x, y) has 3 or more
+// neighbors identical to it, which is what distinguishes a flat-color
+// anti-aliasing "brightest/darkest" endpoint from a genuine edge.
+func hasManySiblings(img *rgbaImage, x, y, w, h int) bool {
+	x0, y0 := max0(x-1), max0(y-1)
+	x1, y1 := min(x+1, w-1), min(y+1, h-1)
+	pos := (y*w + x) * 4
+
+	zeroes := 0
+	if x == x0 || x == x1 || y == y0 || y == y1 {
+		zeroes = 1
+	}
+	for sy := y0; sy <= y1; sy++ {
+		for sx := x0; sx <= x1; sx++ {
+			if sx == x && sy == y {
+				continue
+			}
+			if colorDelta(img, img, pos, (sy*w+sx)*4, true) == 0 {
+				zeroes++
+				if zeroes > 2 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func paintPixel(out draw.Image, x, y int, c color.Color) {
+	if out == nil {
+		return
+	}
+	out.Set(x, y, c)
+}
+
+func paintMatch(out draw.Image, x, y int, img *rgbaImage, pos int, opts Options) {
+	if out == nil {
+		return
+	}
+	if opts.DiffMask {
+		return
+	}
+	r, g, b, a := img.at(pos)
+	y1 := rgb2y(float64(r), float64(g), float64(b))
+	val := uint8(255 + (y1-255)*(opts.Alpha*float64(a)/255))
+	out.Set(x, y, color.RGBA{val, val, val, 255})
+}
+
+func max0(v int) int {
+	if v < 0 {
+		return 0
+	}
+	return v
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func abs64(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
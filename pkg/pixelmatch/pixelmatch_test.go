@@ -0,0 +1,180 @@
+package pixelmatch
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func threshold(v float64) *float64 { return &v }
+
+func solidImage(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCompareIdenticalImagesHaveNoDiff(t *testing.T) {
+	img1 := solidImage(8, 8, color.RGBA{200, 200, 200, 255})
+	img2 := solidImage(8, 8, color.RGBA{200, 200, 200, 255})
+
+	numDiff, err := Compare(img1, img2, nil, Options{})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if numDiff != 0 {
+		t.Errorf("numDiff = %d, want 0", numDiff)
+	}
+}
+
+func TestCompareOppositeColorsAreAllDifferent(t *testing.T) {
+	red := solidImage(8, 8, color.RGBA{255, 0, 0, 255})
+	green := solidImage(8, 8, color.RGBA{0, 255, 0, 255})
+
+	numDiff, err := Compare(red, green, nil, Options{})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if want := 8 * 8; numDiff != want {
+		t.Errorf("numDiff = %d, want %d", numDiff, want)
+	}
+}
+
+func TestCompareNearMissWithinThresholdMatches(t *testing.T) {
+	img1 := solidImage(4, 4, color.RGBA{100, 100, 100, 255})
+	img2 := solidImage(4, 4, color.RGBA{101, 101, 101, 255})
+
+	numDiff, err := Compare(img1, img2, nil, Options{Threshold: threshold(1)})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if numDiff != 0 {
+		t.Errorf("numDiff = %d, want 0 for a near-miss under a loose threshold", numDiff)
+	}
+
+	numDiff, err = Compare(img1, img2, nil, Options{Threshold: threshold(0.001)})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if numDiff != 16 {
+		t.Errorf("numDiff = %d, want 16 for the same near-miss under a strict threshold", numDiff)
+	}
+}
+
+func TestCompareExplicitZeroThresholdIsStrict(t *testing.T) {
+	// A nil Threshold defaults to 0.1, but an explicit &0 must not be
+	// mistaken for "unset" - it's the documented strictest setting and
+	// should catch a one-shade difference that the 0.1 default tolerates.
+	img1 := solidImage(4, 4, color.RGBA{100, 100, 100, 255})
+	img2 := solidImage(4, 4, color.RGBA{101, 101, 101, 255})
+
+	numDiff, err := Compare(img1, img2, nil, Options{Threshold: threshold(0)})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if numDiff != 16 {
+		t.Errorf("numDiff = %d, want 16 for an explicit zero threshold", numDiff)
+	}
+
+	numDiff, err = Compare(img1, img2, nil, Options{})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if numDiff != 0 {
+		t.Errorf("numDiff = %d, want 0 for the default (unset) threshold, to contrast with the explicit zero above", numDiff)
+	}
+}
+
+// antialiasedEdgeImages builds a 5x5 pair where a gray, anti-aliased row in
+// img1 ((x, 2) for every x) sits between a flat white region and a flat
+// black region, while img2 has the same edge one row up with no blending -
+// classic pixelmatch anti-aliasing bait, verified against mapbox's reference
+// algorithm by hand.
+func antialiasedEdgeImages() (img1, img2 *image.RGBA) {
+	white := color.RGBA{255, 255, 255, 255}
+	gray := color.RGBA{128, 128, 128, 255}
+	black := color.RGBA{0, 0, 0, 255}
+
+	img1 = image.NewRGBA(image.Rect(0, 0, 5, 5))
+	img2 = image.NewRGBA(image.Rect(0, 0, 5, 5))
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 5; y++ {
+			switch {
+			case y < 2:
+				img1.Set(x, y, white)
+			case y == 2:
+				img1.Set(x, y, gray)
+			default:
+				img1.Set(x, y, black)
+			}
+
+			if y < 1 {
+				img2.Set(x, y, white)
+			} else {
+				img2.Set(x, y, black)
+			}
+		}
+	}
+	return img1, img2
+}
+
+func TestCompareAntialiasedEdgeIsNotCountedAsDiff(t *testing.T) {
+	img1, img2 := antialiasedEdgeImages()
+
+	// Row 1 is a genuine diff either way (the hard edge shifted up by one
+	// row); row 2 is the graded row and should only count when AA
+	// detection is disabled.
+	numDiff, err := Compare(img1, img2, nil, Options{})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if numDiff != 5 {
+		t.Errorf("numDiff = %d, want 5: only the shifted hard edge, with the graded row exempted as anti-aliasing", numDiff)
+	}
+
+	numDiff, err = Compare(img1, img2, nil, Options{IncludeAA: true})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if numDiff != 10 {
+		t.Errorf("numDiff = %d, want 10 with IncludeAA: true, once the graded row is no longer exempted", numDiff)
+	}
+}
+
+func TestCompareDiffMaskOmitsAntialiasedPixels(t *testing.T) {
+	// Under DiffMask, anti-aliased pixels must be left transparent like
+	// matches are, not painted AAColor - a mask should show only genuine
+	// differences.
+	img1, img2 := antialiasedEdgeImages()
+
+	out := image.NewRGBA(image.Rect(0, 0, 5, 5))
+	numDiff, err := Compare(img1, img2, out, Options{DiffMask: true})
+	if err != nil {
+		t.Fatalf("Compare: %v", err)
+	}
+	if numDiff != 5 {
+		t.Fatalf("numDiff = %d, want 5: precondition for this test is that row 2 (not row 1) is classified as AA", numDiff)
+	}
+
+	if _, _, _, a := out.At(2, 2).RGBA(); a != 0 {
+		t.Errorf("out.At(2, 2) alpha = %d, want 0 (transparent): an anti-aliased pixel must not be painted under DiffMask", a)
+	}
+}
+
+func TestHasManySiblingsSeedsBorderPixelWithOneZero(t *testing.T) {
+	// A 2x2 corner pixel only has 3 neighbors inside the image, so it must
+	// start from zeroes=1 to be treated the same as an interior pixel that
+	// already found one identical sibling - otherwise its 2 identical
+	// neighbors here would fall short of the ">2 identical siblings" cutoff.
+	img := solidImage(2, 2, color.RGBA{0, 0, 0, 255})
+	img.Set(0, 0, color.RGBA{255, 255, 255, 255})
+
+	rgba := newRGBAImage(img)
+	if !hasManySiblings(rgba, 1, 1, 2, 2) {
+		t.Error("hasManySiblings(1, 1) = false, want true: the bottom-right corner has only 3 neighbors, 2 of them identical")
+	}
+}
@@ -0,0 +1,138 @@
+// Package report builds machine-readable summaries of a pixelmatch-dirs run
+// so results can be consumed by CI systems, instead of scraping the tool's
+// human-oriented tabwriter output.
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Status is the outcome of comparing one file.
+type Status string
+
+const (
+	StatusMatch               Status = "match"
+	StatusDifferentPixels     Status = "different_pixels"
+	StatusDifferentDimensions Status = "different_dimensions"
+	StatusMissingTarget       Status = "missing_target"
+)
+
+// Size is an image's pixel dimensions.
+type Size struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Entry is the comparison result for a single file.
+type Entry struct {
+	File       string  `json:"file"`
+	Status     Status  `json:"status"`
+	DiffPixels int     `json:"diffPixels,omitempty"`
+	DiffRatio  float64 `json:"diffRatio,omitempty"`
+	SrcSize    Size    `json:"srcSize,omitempty"`
+	TargetSize Size    `json:"targetSize,omitempty"`
+	DiffImage  string  `json:"diffImage,omitempty"`
+}
+
+// Totals tallies entries by status.
+type Totals struct {
+	Total               int `json:"total"`
+	Match               int `json:"match"`
+	DifferentPixels     int `json:"differentPixels"`
+	DifferentDimensions int `json:"differentDimensions"`
+	MissingTarget       int `json:"missingTarget"`
+}
+
+// Summary is the full report: every entry plus the aggregate totals.
+type Summary struct {
+	Entries []Entry `json:"entries"`
+	Totals  Totals  `json:"totals"`
+}
+
+// NewSummary tallies entries into a Summary.
+func NewSummary(entries []Entry) Summary {
+	totals := Totals{Total: len(entries)}
+	for _, e := range entries {
+		switch e.Status {
+		case StatusMatch:
+			totals.Match++
+		case StatusDifferentPixels:
+			totals.DifferentPixels++
+		case StatusDifferentDimensions:
+			totals.DifferentDimensions++
+		case StatusMissingTarget:
+			totals.MissingTarget++
+		}
+	}
+	return Summary{Entries: entries, Totals: totals}
+}
+
+// WriteJSON writes the summary as indented JSON.
+func (s Summary) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+type junitTestsuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Testcases []junitTestcase `xml:"testcase"`
+}
+
+type junitTestcase struct {
+	Name    string        `xml:"name,attr"`
+	Failure *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the summary as a JUnit XML testsuite, with one
+// <testcase> per entry and a <failure> for anything other than a match, so
+// CI systems that already parse JUnit (Jenkins, GitLab, GitHub Actions) can
+// gate on visual regressions without understanding this tool's own format.
+func (s Summary) WriteJUnit(w io.Writer) error {
+	suite := junitTestsuite{
+		Name:     "pixelmatch-dirs",
+		Tests:    len(s.Entries),
+		Failures: s.Totals.DifferentPixels + s.Totals.DifferentDimensions + s.Totals.MissingTarget,
+	}
+
+	for _, e := range s.Entries {
+		tc := junitTestcase{Name: e.File}
+		switch e.Status {
+		case StatusDifferentPixels:
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d different pixels (%.4f%%)", e.DiffPixels, e.DiffRatio),
+				Text:    e.DiffImage,
+			}
+		case StatusDifferentDimensions:
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("dimensions differ: src %dx%d, target %dx%d",
+					e.SrcSize.Width, e.SrcSize.Height, e.TargetSize.Width, e.TargetSize.Height),
+			}
+		case StatusMissingTarget:
+			tc.Failure = &junitFailure{Message: "missing target file"}
+		}
+		suite.Testcases = append(suite.Testcases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
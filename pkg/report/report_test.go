@@ -0,0 +1,82 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+)
+
+func sampleEntries() []Entry {
+	return []Entry{
+		{File: "a.png", Status: StatusMatch, SrcSize: Size{Width: 8, Height: 8}, TargetSize: Size{Width: 8, Height: 8}},
+		{
+			File: "b.png", Status: StatusDifferentPixels, DiffPixels: 4, DiffRatio: 6.25,
+			SrcSize: Size{Width: 8, Height: 8}, TargetSize: Size{Width: 8, Height: 8}, DiffImage: "diff-b.png",
+		},
+		{File: "c.png", Status: StatusDifferentDimensions, SrcSize: Size{Width: 8, Height: 8}, TargetSize: Size{Width: 4, Height: 4}},
+		{File: "d.png", Status: StatusMissingTarget},
+	}
+}
+
+func TestNewSummaryTallies(t *testing.T) {
+	summary := NewSummary(sampleEntries())
+
+	want := Totals{Total: 4, Match: 1, DifferentPixels: 1, DifferentDimensions: 1, MissingTarget: 1}
+	if summary.Totals != want {
+		t.Errorf("Totals = %+v, want %+v", summary.Totals, want)
+	}
+}
+
+func TestWriteJSONRoundTrips(t *testing.T) {
+	summary := NewSummary(sampleEntries())
+
+	var buf bytes.Buffer
+	if err := summary.WriteJSON(&buf); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+
+	var got Summary
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(got.Entries) != len(summary.Entries) {
+		t.Fatalf("got %d entries, want %d", len(got.Entries), len(summary.Entries))
+	}
+	if got.Entries[1].DiffImage != "diff-b.png" {
+		t.Errorf("Entries[1].DiffImage = %q, want %q", got.Entries[1].DiffImage, "diff-b.png")
+	}
+	if got.Totals != summary.Totals {
+		t.Errorf("Totals = %+v, want %+v", got.Totals, summary.Totals)
+	}
+}
+
+func TestWriteJUnitCountsFailures(t *testing.T) {
+	summary := NewSummary(sampleEntries())
+
+	var buf bytes.Buffer
+	if err := summary.WriteJUnit(&buf); err != nil {
+		t.Fatalf("WriteJUnit: %v", err)
+	}
+
+	var suite junitTestsuite
+	if err := xml.Unmarshal(buf.Bytes(), &suite); err != nil {
+		t.Fatalf("Unmarshal: %v\n%s", err, buf.String())
+	}
+	if suite.Tests != 4 {
+		t.Errorf("Tests = %d, want 4", suite.Tests)
+	}
+	if suite.Failures != 3 {
+		t.Errorf("Failures = %d, want 3", suite.Failures)
+	}
+
+	failing := 0
+	for _, tc := range suite.Testcases {
+		if tc.Failure != nil {
+			failing++
+		}
+	}
+	if failing != suite.Failures {
+		t.Errorf("found %d <failure> elements, want %d", failing, suite.Failures)
+	}
+}